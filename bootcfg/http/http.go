@@ -0,0 +1,138 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package http routes provisioning clients' requests for Ignition configs,
+// Cloud-Configs, iPXE/GRUB scripts, and static assets. Only /assets/ serves
+// content; the /ignition, /cloud, /ipxe, /grub, and /boot.ipxe handlers
+// respond 501 Not Implemented.
+package http
+
+import (
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/coreos/coreos-baremetal/bootcfg/server"
+	"github.com/coreos/coreos-baremetal/bootcfg/sign"
+)
+
+// Config configures a Server.
+type Config struct {
+	Core          *server.Server
+	Logger        *logrus.Logger
+	AssetsPath    string
+	Signer        sign.Signer
+	ArmoredSigner sign.Signer
+}
+
+// Server serves the bootcfg HTTP API.
+type Server struct {
+	core          *server.Server
+	logger        *logrus.Logger
+	assetsPath    string
+	signer        sign.Signer
+	armoredSigner sign.Signer
+	mux           *http.ServeMux
+}
+
+// NewServer returns a new HTTP Server.
+func NewServer(config *Config) *Server {
+	s := &Server{
+		core:          config.Core,
+		logger:        config.Logger,
+		assetsPath:    config.AssetsPath,
+		signer:        config.Signer,
+		armoredSigner: config.ArmoredSigner,
+		mux:           http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/ignition", s.ignitionHandler)
+	s.mux.HandleFunc("/cloud", s.cloudHandler)
+	s.mux.HandleFunc("/ipxe", s.ipxeHandler)
+	s.mux.HandleFunc("/grub", s.grubHandler)
+	s.mux.HandleFunc("/boot.ipxe", s.bootIpxeHandler)
+	if s.assetsPath != "" {
+		s.mux.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir(s.assetsPath))))
+	}
+	return s
+}
+
+// HTTPHandler returns the root http.Handler for the Server, with logging
+// and metrics middleware applied.
+func (s *Server) HTTPHandler() http.Handler {
+	return s.logMiddleware(s.metricsMiddleware(s.mux))
+}
+
+func (s *Server) logMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		mac := query.Get("mac")
+		uuid := query.Get("uuid")
+
+		fields := logrus.Fields{
+			"client_ip": clientIP(req),
+			"path":      req.URL.Path,
+			"profile":   query.Get("profile"),
+			"mac":       mac,
+			"uuid":      uuid,
+		}
+		// GroupFor does a full Store.Groups() listing, so only pay for it when
+		// the request actually carries a mac/uuid to match (ruling out plain
+		// /assets/* fetches) and debug logging is enabled to consume it.
+		if (mac != "" || uuid != "") && s.logger.Level >= logrus.DebugLevel {
+			if group, err := s.core.GroupFor(map[string]string{"mac": mac, "uuid": uuid}); err == nil {
+				fields["group"] = group.ID
+			}
+		}
+
+		s.logger.WithFields(fields).Debug("http request")
+		next.ServeHTTP(w, req)
+	})
+}
+
+func clientIP(req *http.Request) string {
+	host := req.RemoteAddr
+	if idx := lastColon(host); idx != -1 {
+		host = host[:idx]
+	}
+	return host
+}
+
+func lastColon(s string) int {
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] == ':' {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Server) ignitionHandler(w http.ResponseWriter, req *http.Request) {
+	http.Error(w, "not implemented", http.StatusNotImplemented)
+}
+
+func (s *Server) cloudHandler(w http.ResponseWriter, req *http.Request) {
+	http.Error(w, "not implemented", http.StatusNotImplemented)
+}
+
+func (s *Server) ipxeHandler(w http.ResponseWriter, req *http.Request) {
+	http.Error(w, "not implemented", http.StatusNotImplemented)
+}
+
+func (s *Server) grubHandler(w http.ResponseWriter, req *http.Request) {
+	http.Error(w, "not implemented", http.StatusNotImplemented)
+}
+
+func (s *Server) bootIpxeHandler(w http.ResponseWriter, req *http.Request) {
+	http.Error(w, "not implemented", http.StatusNotImplemented)
+}
@@ -0,0 +1,124 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// endpoints are the paths that get their own metrics label, so dashboards
+// can break down hit/miss/render-error rates per rendered artifact.
+var endpoints = []string{"/ignition", "/cloud", "/ipxe", "/grub", "/assets", "/boot.ipxe"}
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bootcfg",
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Total number of HTTP requests by endpoint and result.",
+	}, []string{"endpoint", "result"})
+
+	groupsLoaded = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bootcfg",
+		Subsystem: "storage",
+		Name:      "groups_loaded",
+		Help:      "Number of Groups currently loaded from storage.",
+	})
+	profilesLoaded = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bootcfg",
+		Subsystem: "storage",
+		Name:      "profiles_loaded",
+		Help:      "Number of Profiles currently loaded from storage.",
+	})
+	machinesLoaded = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bootcfg",
+		Subsystem: "storage",
+		Name:      "machines_loaded",
+		Help:      "Number of Groups with machine selectors currently loaded from storage.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal, groupsLoaded, profilesLoaded, machinesLoaded)
+}
+
+// metricsMiddleware labels every request by the matched endpoint and by
+// whether it was served, missed, or failed to render.
+func (s *Server) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, req)
+		requestsTotal.WithLabelValues(endpointLabel(req.URL.Path), resultLabel(rec.status)).Inc()
+	})
+}
+
+func endpointLabel(path string) string {
+	for _, e := range endpoints {
+		if path == e || (e == "/assets" && strings.HasPrefix(path, "/assets/")) {
+			return e
+		}
+	}
+	return "other"
+}
+
+func resultLabel(status int) string {
+	switch {
+	case status == http.StatusNotFound:
+		return "miss"
+	case status >= 500:
+		return "render-error"
+	default:
+		return "hit"
+	}
+}
+
+// RefreshStorageGauges recomputes the Groups/Profiles/Machines gauges from
+// the current state of the Store. Callers should invoke this periodically
+// or whenever storage changes are detected.
+func (s *Server) RefreshStorageGauges() error {
+	groups, err := s.core.Store().Groups()
+	if err != nil {
+		return err
+	}
+	groupsLoaded.Set(float64(len(groups)))
+
+	machines := 0
+	for _, g := range groups {
+		if len(g.Selectors) > 0 {
+			machines++
+		}
+	}
+	machinesLoaded.Set(float64(machines))
+
+	profiles, err := s.core.Store().Profiles()
+	if err != nil {
+		return err
+	}
+	profilesLoaded.Set(float64(len(profiles)))
+	return nil
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,58 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestEndpointLabel(t *testing.T) {
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"/ignition", "/ignition"},
+		{"/cloud", "/cloud"},
+		{"/ipxe", "/ipxe"},
+		{"/assets", "/assets"},
+		{"/assets/coreos/vmlinuz", "/assets"},
+		{"/metadata", "other"},
+		{"", "other"},
+	}
+	for _, c := range cases {
+		if got := endpointLabel(c.path); got != c.want {
+			t.Errorf("endpointLabel(%q) = %q, want %q", c.path, got, c.want)
+		}
+	}
+}
+
+func TestResultLabel(t *testing.T) {
+	cases := []struct {
+		status int
+		want   string
+	}{
+		{http.StatusOK, "hit"},
+		{http.StatusNotFound, "miss"},
+		{http.StatusInternalServerError, "render-error"},
+		{http.StatusBadGateway, "render-error"},
+		{http.StatusMovedPermanently, "hit"},
+	}
+	for _, c := range cases {
+		if got := resultLabel(c.status); got != c.want {
+			t.Errorf("resultLabel(%d) = %q, want %q", c.status, got, c.want)
+		}
+	}
+}
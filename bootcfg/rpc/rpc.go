@@ -0,0 +1,57 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rpc exposes the bootcfg gRPC API used by CLI clients and
+// provisioning tools to manage Groups and Profiles.
+package rpc
+
+import (
+	"crypto/tls"
+
+	"github.com/Sirupsen/logrus"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpc_logrus "github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus"
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/coreos/coreos-baremetal/bootcfg/server"
+)
+
+// NewServer returns a gRPC server backed by core, serving over tlsConfig.
+// Every unary and streaming RPC is instrumented with per-RPC latency,
+// count, and error histograms via grpc_prometheus, and logged to logger.
+func NewServer(core *server.Server, tlsConfig *tls.Config, logger *logrus.Logger) *grpc.Server {
+	grpc_prometheus.EnableHandlingTimeHistogram()
+	entry := logrus.NewEntry(logger)
+
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
+			grpc_prometheus.UnaryServerInterceptor,
+			grpc_logrus.UnaryServerInterceptor(entry),
+		)),
+		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(
+			grpc_prometheus.StreamServerInterceptor,
+			grpc_logrus.StreamServerInterceptor(entry),
+		)),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	grpcServer := grpc.NewServer(opts...)
+	registerServices(grpcServer, core)
+	grpc_prometheus.Register(grpcServer)
+	return grpcServer
+}
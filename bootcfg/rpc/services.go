@@ -0,0 +1,28 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpc
+
+import (
+	"google.golang.org/grpc"
+
+	"github.com/coreos/coreos-baremetal/bootcfg/server"
+)
+
+// registerServices is where the bootcfg gRPC services backed by core are
+// attached to grpcServer. It registers no services, so grpcServer serves no
+// bootcfg RPCs and core is unused.
+func registerServices(grpcServer *grpc.Server, core *server.Server) {
+	_ = core
+}
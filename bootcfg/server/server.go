@@ -0,0 +1,71 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package server implements the core Group/Profile matching logic shared by
+// the HTTP and gRPC frontends.
+package server
+
+import (
+	"errors"
+
+	"github.com/coreos/coreos-baremetal/bootcfg/storage"
+)
+
+// ErrGroupNotFound is returned when no Group selectors match a machine.
+var ErrGroupNotFound = errors.New("server: no matching group")
+
+// Config configures a Server.
+type Config struct {
+	Store storage.Store
+}
+
+// Server matches machine requests to Groups/Profiles and renders their
+// templates.
+type Server struct {
+	store storage.Store
+}
+
+// NewServer returns a Server backed by config.Store.
+func NewServer(config *Config) *Server {
+	return &Server{store: config.Store}
+}
+
+// Store returns the underlying storage.Store.
+func (s *Server) Store() storage.Store {
+	return s.store
+}
+
+// GroupFor returns the Group whose selectors match the given labels
+// (e.g. mac, uuid), or ErrGroupNotFound if none match.
+func (s *Server) GroupFor(labels map[string]string) (*storage.Group, error) {
+	groups, err := s.store.Groups()
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		if matches(group.Selectors, labels) {
+			return group, nil
+		}
+	}
+	return nil, ErrGroupNotFound
+}
+
+func matches(selectors, labels map[string]string) bool {
+	for k, v := range selectors {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sign signs rendered configs with a GPG keyring.
+package sign
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// Signer produces a detached signature for arbitrary content.
+type Signer interface {
+	Sign(content []byte) ([]byte, error)
+}
+
+type gpgSigner struct {
+	entity  *openpgp.Entity
+	armored bool
+}
+
+// NewGPGSigner returns a Signer that produces binary OpenPGP signatures
+// using entity.
+func NewGPGSigner(entity *openpgp.Entity) Signer {
+	return &gpgSigner{entity: entity}
+}
+
+// NewArmoredGPGSigner returns a Signer that produces ASCII-armored OpenPGP
+// signatures using entity.
+func NewArmoredGPGSigner(entity *openpgp.Entity) Signer {
+	return &gpgSigner{entity: entity, armored: true}
+}
+
+func (s *gpgSigner) Sign(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if s.armored {
+		err = openpgp.ArmoredDetachSign(&buf, s.entity, bytes.NewReader(content), nil)
+	} else {
+		err = openpgp.DetachSign(&buf, s.entity, bytes.NewReader(content), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// LoadGPGEntity reads and decrypts the first private key entity found in
+// the keyring at path, using passphrase if the key is encrypted and
+// passphrase is non-empty.
+func LoadGPGEntity(path, passphrase string) (*openpgp.Entity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	entityList, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	if err != nil {
+		entityList, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var entity *openpgp.Entity
+	for _, e := range entityList {
+		if e.PrivateKey != nil {
+			entity = e
+			break
+		}
+	}
+	if entity == nil {
+		return nil, errors.New("sign: no private key found in keyring")
+	}
+	if entity.PrivateKey.Encrypted && passphrase != "" {
+		if err := entity.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+			return nil, err
+		}
+	}
+	return entity, nil
+}
@@ -0,0 +1,272 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// ConsulConfig configures a ConsulStore.
+type ConsulConfig struct {
+	Address string
+	Token   string
+	// Prefix namespaces all Group/Profile/Ignition/Cloud/Generic keys,
+	// e.g. "bootcfg".
+	Prefix string
+}
+
+// ConsulStore is a Store backed by the Consul KV store, kept warm by a
+// background blocking-query loop so hot changes to Groups/Profiles are
+// picked up without a restart.
+type ConsulStore struct {
+	kv     *api.KV
+	prefix string
+
+	mu        sync.RWMutex
+	groups    map[string]*Group
+	profiles  map[string]*Profile
+	lastIndex uint64
+
+	stop chan struct{}
+}
+
+// NewConsulStore connects to the Consul agent named by config, loads the
+// current Groups/Profiles into an in-memory cache, and starts watching for
+// changes.
+func NewConsulStore(config *ConsulConfig) (*ConsulStore, error) {
+	clientConfig := api.DefaultConfig()
+	if config.Address != "" {
+		clientConfig.Address = config.Address
+	}
+	if config.Token != "" {
+		clientConfig.Token = config.Token
+	}
+	client, err := api.NewClient(clientConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ConsulStore{
+		kv:       client.KV(),
+		prefix:   strings.TrimSuffix(config.Prefix, "/"),
+		groups:   make(map[string]*Group),
+		profiles: make(map[string]*Profile),
+		stop:     make(chan struct{}),
+	}
+	if err := s.reload(0); err != nil {
+		return nil, err
+	}
+	go s.watch()
+	return s, nil
+}
+
+func (s *ConsulStore) groupKey(id string) string   { return s.prefix + "/groups/" + id }
+func (s *ConsulStore) profileKey(id string) string { return s.prefix + "/profiles/" + id }
+
+// reload runs a (blocking, if waitIndex > 0) query for the whole prefix tree
+// and rebuilds the in-memory cache from the result.
+func (s *ConsulStore) reload(waitIndex uint64) error {
+	pairs, meta, err := s.kv.List(s.prefix+"/", &api.QueryOptions{WaitIndex: waitIndex})
+	if err != nil {
+		return err
+	}
+
+	groups := make(map[string]*Group)
+	profiles := make(map[string]*Profile)
+	for _, pair := range pairs {
+		switch {
+		case strings.HasPrefix(pair.Key, s.prefix+"/groups/"):
+			group := new(Group)
+			if err := json.Unmarshal(pair.Value, group); err != nil {
+				return err
+			}
+			groups[group.ID] = group
+		case strings.HasPrefix(pair.Key, s.prefix+"/profiles/"):
+			profile := new(Profile)
+			if err := json.Unmarshal(pair.Value, profile); err != nil {
+				return err
+			}
+			profiles[profile.ID] = profile
+		}
+	}
+
+	s.mu.Lock()
+	s.groups = groups
+	s.profiles = profiles
+	s.mu.Unlock()
+
+	s.lastIndex = meta.LastIndex
+	return nil
+}
+
+// consulWatchRetryDelay bounds how often watch retries reload after an
+// error, so an unreachable Consul agent does not spin the loop at 100% CPU.
+const consulWatchRetryDelay = 5 * time.Second
+
+func (s *ConsulStore) watch() {
+	var waitIndex uint64
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		if err := s.reload(waitIndex); err != nil {
+			select {
+			case <-s.stop:
+				return
+			case <-time.After(consulWatchRetryDelay):
+			}
+			continue
+		}
+		waitIndex = s.lastIndex
+	}
+}
+
+// Close stops the background watch.
+func (s *ConsulStore) Close() error {
+	close(s.stop)
+	return nil
+}
+
+// GroupPut writes a Group definition to Consul.
+func (s *ConsulStore) GroupPut(group *Group) error {
+	data, err := json.Marshal(group)
+	if err != nil {
+		return err
+	}
+	_, err = s.kv.Put(&api.KVPair{Key: s.groupKey(group.ID), Value: data}, nil)
+	return err
+}
+
+// GroupGet returns a cached Group definition by ID.
+func (s *ConsulStore) GroupGet(id string) (*Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	group, ok := s.groups[id]
+	if !ok {
+		return nil, fmt.Errorf("storage: group %q not found", id)
+	}
+	return group, nil
+}
+
+// GroupDelete removes a Group definition from Consul.
+func (s *ConsulStore) GroupDelete(id string) error {
+	_, err := s.kv.Delete(s.groupKey(id), nil)
+	return err
+}
+
+// Groups returns all cached Group definitions.
+func (s *ConsulStore) Groups() ([]*Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	groups := make([]*Group, 0, len(s.groups))
+	for _, g := range s.groups {
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// ProfilePut writes a Profile definition to Consul.
+func (s *ConsulStore) ProfilePut(profile *Profile) error {
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	_, err = s.kv.Put(&api.KVPair{Key: s.profileKey(profile.ID), Value: data}, nil)
+	return err
+}
+
+// ProfileGet returns a cached Profile definition by ID.
+func (s *ConsulStore) ProfileGet(id string) (*Profile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	profile, ok := s.profiles[id]
+	if !ok {
+		return nil, fmt.Errorf("storage: profile %q not found", id)
+	}
+	return profile, nil
+}
+
+// ProfileDelete removes a Profile definition from Consul.
+func (s *ConsulStore) ProfileDelete(id string) error {
+	_, err := s.kv.Delete(s.profileKey(id), nil)
+	return err
+}
+
+// Profiles returns all cached Profile definitions.
+func (s *ConsulStore) Profiles() ([]*Profile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	profiles := make([]*Profile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// IgnitionGet reads a named Ignition config template.
+func (s *ConsulStore) IgnitionGet(name string) ([]byte, error) {
+	return s.getRaw("ignition", name)
+}
+
+// IgnitionPut writes a named Ignition config template.
+func (s *ConsulStore) IgnitionPut(name string, content []byte) error {
+	return s.putRaw("ignition", name, content)
+}
+
+// CloudGet reads a named Cloud-Config template.
+func (s *ConsulStore) CloudGet(name string) ([]byte, error) {
+	return s.getRaw("cloud", name)
+}
+
+// CloudPut writes a named Cloud-Config template.
+func (s *ConsulStore) CloudPut(name string, content []byte) error {
+	return s.putRaw("cloud", name, content)
+}
+
+// GenericGet reads a named generic template, used for iPXE/GRUB configs.
+func (s *ConsulStore) GenericGet(name string) ([]byte, error) {
+	return s.getRaw("generic", name)
+}
+
+// GenericPut writes a named generic template.
+func (s *ConsulStore) GenericPut(name string, content []byte) error {
+	return s.putRaw("generic", name, content)
+}
+
+func (s *ConsulStore) getRaw(kind, name string) ([]byte, error) {
+	pair, _, err := s.kv.Get(s.prefix+"/"+kind+"/"+name, nil)
+	if err != nil {
+		return nil, err
+	}
+	if pair == nil {
+		return nil, fmt.Errorf("storage: %s %q not found", kind, name)
+	}
+	return pair.Value, nil
+}
+
+func (s *ConsulStore) putRaw(kind, name string, content []byte) error {
+	_, err := s.kv.Put(&api.KVPair{Key: s.prefix + "/" + kind + "/" + name, Value: content}, nil)
+	return err
+}
+
+var _ Store = &ConsulStore{}
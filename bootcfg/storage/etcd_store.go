@@ -0,0 +1,331 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+)
+
+// EtcdConfig configures an EtcdStore.
+type EtcdConfig struct {
+	Endpoints []string
+	// Prefix namespaces all Group/Profile/Ignition/Cloud/Generic keys,
+	// e.g. "/bootcfg".
+	Prefix      string
+	TLSCert     string
+	TLSKey      string
+	TLSCA       string
+	DialTimeout time.Duration
+}
+
+// EtcdStore is a Store backed by etcd v3, kept warm by a background watch
+// so hot changes to Groups/Profiles are picked up without a restart.
+type EtcdStore struct {
+	client *clientv3.Client
+	prefix string
+
+	mu       sync.RWMutex
+	groups   map[string]*Group
+	profiles map[string]*Profile
+
+	cancel context.CancelFunc
+}
+
+// NewEtcdStore connects to the etcd cluster named by config, loads the
+// current Groups/Profiles into an in-memory cache, and starts watching for
+// changes.
+func NewEtcdStore(config *EtcdConfig) (*EtcdStore, error) {
+	tlsConfig, err := etcdTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	dialTimeout := config.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = 5 * time.Second
+	}
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   config.Endpoints,
+		DialTimeout: dialTimeout,
+		TLS:         tlsConfig,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &EtcdStore{
+		client:   client,
+		prefix:   strings.TrimSuffix(config.Prefix, "/"),
+		groups:   make(map[string]*Group),
+		profiles: make(map[string]*Profile),
+		cancel:   cancel,
+	}
+	if err := s.loadCache(ctx); err != nil {
+		cancel()
+		client.Close()
+		return nil, err
+	}
+	go s.watch(ctx)
+	return s, nil
+}
+
+func etcdTLSConfig(config *EtcdConfig) (*tls.Config, error) {
+	if config.TLSCert == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(config.TLSCert, config.TLSKey)
+	if err != nil {
+		return nil, err
+	}
+	caPEM, err := ioutil.ReadFile(config.TLSCA)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("storage: unable to parse etcd CA certificate %q", config.TLSCA)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, RootCAs: pool}, nil
+}
+
+func (s *EtcdStore) groupKey(id string) string   { return s.prefix + "/groups/" + id }
+func (s *EtcdStore) profileKey(id string) string { return s.prefix + "/profiles/" + id }
+
+func (s *EtcdStore) loadCache(ctx context.Context) error {
+	groupResp, err := s.client.Get(ctx, s.prefix+"/groups/", clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	profileResp, err := s.client.Get(ctx, s.prefix+"/profiles/", clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, kv := range groupResp.Kvs {
+		group := new(Group)
+		if err := json.Unmarshal(kv.Value, group); err != nil {
+			return err
+		}
+		s.groups[group.ID] = group
+	}
+	for _, kv := range profileResp.Kvs {
+		profile := new(Profile)
+		if err := json.Unmarshal(kv.Value, profile); err != nil {
+			return err
+		}
+		s.profiles[profile.ID] = profile
+	}
+	return nil
+}
+
+// etcdWatchRetryDelay bounds how often watch re-establishes its etcd watch
+// channel after the channel closes unexpectedly (e.g. compaction or a
+// connection drop), mirroring ConsulStore's reload backoff.
+const etcdWatchRetryDelay = 5 * time.Second
+
+// watch keeps the in-memory Group/Profile cache in sync with etcd until ctx
+// is cancelled. The watch channel can close on its own (a compacted
+// revision, a connection drop the client gives up retrying); when that
+// happens watch re-establishes it after a backoff rather than leaving the
+// cache frozen.
+func (s *EtcdStore) watch(ctx context.Context) {
+	for {
+		watchCh := s.client.Watch(ctx, s.prefix+"/", clientv3.WithPrefix())
+		for resp := range watchCh {
+			if err := resp.Err(); err != nil {
+				break
+			}
+			for _, ev := range resp.Events {
+				s.applyEvent(ev)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(etcdWatchRetryDelay):
+		}
+	}
+}
+
+func (s *EtcdStore) applyEvent(ev *clientv3.Event) {
+	key := string(ev.Kv.Key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case strings.HasPrefix(key, s.prefix+"/groups/"):
+		id := strings.TrimPrefix(key, s.prefix+"/groups/")
+		if ev.Type == clientv3.EventTypeDelete {
+			delete(s.groups, id)
+			return
+		}
+		group := new(Group)
+		if err := json.Unmarshal(ev.Kv.Value, group); err == nil {
+			s.groups[id] = group
+		}
+	case strings.HasPrefix(key, s.prefix+"/profiles/"):
+		id := strings.TrimPrefix(key, s.prefix+"/profiles/")
+		if ev.Type == clientv3.EventTypeDelete {
+			delete(s.profiles, id)
+			return
+		}
+		profile := new(Profile)
+		if err := json.Unmarshal(ev.Kv.Value, profile); err == nil {
+			s.profiles[id] = profile
+		}
+	}
+}
+
+// Close stops the background watch and releases the etcd client.
+func (s *EtcdStore) Close() error {
+	s.cancel()
+	return s.client.Close()
+}
+
+// GroupPut writes a Group definition to etcd.
+func (s *EtcdStore) GroupPut(group *Group) error {
+	data, err := json.Marshal(group)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Put(context.Background(), s.groupKey(group.ID), string(data))
+	return err
+}
+
+// GroupGet returns a cached Group definition by ID.
+func (s *EtcdStore) GroupGet(id string) (*Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	group, ok := s.groups[id]
+	if !ok {
+		return nil, fmt.Errorf("storage: group %q not found", id)
+	}
+	return group, nil
+}
+
+// GroupDelete removes a Group definition from etcd.
+func (s *EtcdStore) GroupDelete(id string) error {
+	_, err := s.client.Delete(context.Background(), s.groupKey(id))
+	return err
+}
+
+// Groups returns all cached Group definitions.
+func (s *EtcdStore) Groups() ([]*Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	groups := make([]*Group, 0, len(s.groups))
+	for _, g := range s.groups {
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// ProfilePut writes a Profile definition to etcd.
+func (s *EtcdStore) ProfilePut(profile *Profile) error {
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Put(context.Background(), s.profileKey(profile.ID), string(data))
+	return err
+}
+
+// ProfileGet returns a cached Profile definition by ID.
+func (s *EtcdStore) ProfileGet(id string) (*Profile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	profile, ok := s.profiles[id]
+	if !ok {
+		return nil, fmt.Errorf("storage: profile %q not found", id)
+	}
+	return profile, nil
+}
+
+// ProfileDelete removes a Profile definition from etcd.
+func (s *EtcdStore) ProfileDelete(id string) error {
+	_, err := s.client.Delete(context.Background(), s.profileKey(id))
+	return err
+}
+
+// Profiles returns all cached Profile definitions.
+func (s *EtcdStore) Profiles() ([]*Profile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	profiles := make([]*Profile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// IgnitionGet reads a named Ignition config template.
+func (s *EtcdStore) IgnitionGet(name string) ([]byte, error) {
+	return s.getRaw("ignition", name)
+}
+
+// IgnitionPut writes a named Ignition config template.
+func (s *EtcdStore) IgnitionPut(name string, content []byte) error {
+	return s.putRaw("ignition", name, content)
+}
+
+// CloudGet reads a named Cloud-Config template.
+func (s *EtcdStore) CloudGet(name string) ([]byte, error) {
+	return s.getRaw("cloud", name)
+}
+
+// CloudPut writes a named Cloud-Config template.
+func (s *EtcdStore) CloudPut(name string, content []byte) error {
+	return s.putRaw("cloud", name, content)
+}
+
+// GenericGet reads a named generic template, used for iPXE/GRUB configs.
+func (s *EtcdStore) GenericGet(name string) ([]byte, error) {
+	return s.getRaw("generic", name)
+}
+
+// GenericPut writes a named generic template.
+func (s *EtcdStore) GenericPut(name string, content []byte) error {
+	return s.putRaw("generic", name, content)
+}
+
+func (s *EtcdStore) getRaw(kind, name string) ([]byte, error) {
+	resp, err := s.client.Get(context.Background(), s.prefix+"/"+kind+"/"+name)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, fmt.Errorf("storage: %s %q not found", kind, name)
+	}
+	return resp.Kvs[0].Value, nil
+}
+
+func (s *EtcdStore) putRaw(kind, name string, content []byte) error {
+	_, err := s.client.Put(context.Background(), s.prefix+"/"+kind+"/"+name, string(content))
+	return err
+}
+
+var _ Store = &EtcdStore{}
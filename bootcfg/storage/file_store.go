@@ -0,0 +1,217 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Config configures a FileStore.
+type Config struct {
+	// Root is the base directory containing groups/, profiles/, ignition/,
+	// cloud/, and generic/ subdirectories.
+	Root string
+	// Logger receives a debug-level trace of each GroupPut; other reads and
+	// writes are not logged. Defaults to
+	// a standard logrus logger at its default level when nil.
+	Logger *logrus.Logger
+}
+
+// FileStore is a Store backed by a directory tree on local disk.
+type FileStore struct {
+	root   string
+	logger *logrus.Logger
+	mu     sync.RWMutex
+}
+
+var _ Store = &FileStore{}
+
+// NewFileStore returns a Store which reads and writes objects under
+// config.Root.
+func NewFileStore(config *Config) *FileStore {
+	logger := config.Logger
+	if logger == nil {
+		logger = logrus.New()
+	}
+	return &FileStore{root: config.Root, logger: logger}
+}
+
+// Close is a no-op: FileStore holds no background goroutines or connections.
+func (s *FileStore) Close() error { return nil }
+
+func (s *FileStore) groupPath(id string) string   { return filepath.Join(s.root, "groups", id+".json") }
+func (s *FileStore) profilePath(id string) string { return filepath.Join(s.root, "profiles", id+".json") }
+
+// GroupPut writes a Group definition to disk.
+func (s *FileStore) GroupPut(group *Group) error {
+	s.logger.Debugf("writing group %s", group.ID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.Marshal(group)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.groupPath(group.ID), data, 0644)
+}
+
+// GroupGet reads a Group definition by ID.
+func (s *FileStore) GroupGet(id string) (*Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, err := ioutil.ReadFile(s.groupPath(id))
+	if err != nil {
+		return nil, err
+	}
+	group := new(Group)
+	if err := json.Unmarshal(data, group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// GroupDelete removes a Group definition.
+func (s *FileStore) GroupDelete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return os.Remove(s.groupPath(id))
+}
+
+// Groups returns all known Group definitions.
+func (s *FileStore) Groups() ([]*Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	files, err := ioutil.ReadDir(filepath.Join(s.root, "groups"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]*Group, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(s.root, "groups", f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		group := new(Group)
+		if err := json.Unmarshal(data, group); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// ProfilePut writes a Profile definition to disk.
+func (s *FileStore) ProfilePut(profile *Profile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.profilePath(profile.ID), data, 0644)
+}
+
+// ProfileGet reads a Profile definition by ID.
+func (s *FileStore) ProfileGet(id string) (*Profile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, err := ioutil.ReadFile(s.profilePath(id))
+	if err != nil {
+		return nil, err
+	}
+	profile := new(Profile)
+	if err := json.Unmarshal(data, profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// ProfileDelete removes a Profile definition.
+func (s *FileStore) ProfileDelete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return os.Remove(s.profilePath(id))
+}
+
+// Profiles returns all known Profile definitions.
+func (s *FileStore) Profiles() ([]*Profile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	files, err := ioutil.ReadDir(filepath.Join(s.root, "profiles"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	profiles := make([]*Profile, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(s.root, "profiles", f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		profile := new(Profile)
+		if err := json.Unmarshal(data, profile); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// IgnitionGet reads a named Ignition config template.
+func (s *FileStore) IgnitionGet(name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(s.root, "ignition", name))
+}
+
+// IgnitionPut writes a named Ignition config template.
+func (s *FileStore) IgnitionPut(name string, content []byte) error {
+	return ioutil.WriteFile(filepath.Join(s.root, "ignition", name), content, 0644)
+}
+
+// CloudGet reads a named Cloud-Config template.
+func (s *FileStore) CloudGet(name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(s.root, "cloud", name))
+}
+
+// CloudPut writes a named Cloud-Config template.
+func (s *FileStore) CloudPut(name string, content []byte) error {
+	return ioutil.WriteFile(filepath.Join(s.root, "cloud", name), content, 0644)
+}
+
+// GenericGet reads a named generic template, used for iPXE/GRUB configs.
+func (s *FileStore) GenericGet(name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(s.root, "generic", name))
+}
+
+// GenericPut writes a named generic template.
+func (s *FileStore) GenericPut(name string, content []byte) error {
+	return ioutil.WriteFile(filepath.Join(s.root, "generic", name), content, 0644)
+}
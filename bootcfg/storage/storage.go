@@ -0,0 +1,67 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storage defines the Group/Profile/Ignition/CloudConfig/Generic
+// objects bootcfg serves and the Store interface used to persist them.
+package storage
+
+// Group maps machine selectors to a Profile and arbitrary metadata that is
+// rendered into the Profile's templates.
+type Group struct {
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	Profile   string                 `json:"profile"`
+	Selectors map[string]string      `json:"selectors,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Profile names the Ignition config, Cloud-Config, and network boot settings
+// that are rendered for machines matching a Group.
+type Profile struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	IgnitionID string   `json:"ignition_id,omitempty"`
+	CloudID    string   `json:"cloud_id,omitempty"`
+	GenericID  string   `json:"generic_id,omitempty"`
+	Kernel     string   `json:"kernel,omitempty"`
+	Initrd     []string `json:"initrd,omitempty"`
+	Args       []string `json:"args,omitempty"`
+}
+
+// Store describes a storage backend for Groups, Profiles, and the template
+// files they reference. Implementations must be safe for concurrent use.
+type Store interface {
+	// Close releases any background goroutines or connections the Store
+	// holds. Callers must call Close when done with a Store.
+	Close() error
+
+	GroupPut(group *Group) error
+	GroupGet(id string) (*Group, error)
+	GroupDelete(id string) error
+	Groups() ([]*Group, error)
+
+	ProfilePut(profile *Profile) error
+	ProfileGet(id string) (*Profile, error)
+	ProfileDelete(id string) error
+	Profiles() ([]*Profile, error)
+
+	IgnitionPut(name string, content []byte) error
+	IgnitionGet(name string) ([]byte, error)
+
+	CloudGet(name string) ([]byte, error)
+	CloudPut(name string, content []byte) error
+
+	GenericGet(name string) ([]byte, error)
+	GenericPut(name string, content []byte) error
+}
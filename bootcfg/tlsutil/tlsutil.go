@@ -0,0 +1,123 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tlsutil builds tls.Config values for the bootcfg gRPC server and
+// its clients from PEM-encoded certificate, key, and CA files.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+)
+
+// ClientAuthType is the policy the gRPC server applies to client
+// certificates presented during the handshake.
+type ClientAuthType int
+
+const (
+	// NoClientCert accepts connections without a client certificate.
+	NoClientCert ClientAuthType = iota
+	// VerifyClientCertIfGiven verifies a client certificate if one is
+	// presented, but does not require one.
+	VerifyClientCertIfGiven
+	// RequireAndVerifyClientCert requires a valid client certificate
+	// signed by the configured CA on every connection.
+	RequireAndVerifyClientCert
+)
+
+func (t ClientAuthType) tls() tls.ClientAuthType {
+	switch t {
+	case VerifyClientCertIfGiven:
+		return tls.VerifyClientCertIfGiven
+	case RequireAndVerifyClientCert:
+		return tls.RequireAndVerifyClientCert
+	default:
+		return tls.NoClientCert
+	}
+}
+
+// ServerTLSOptions configures the certificate, CA, client authentication
+// policy, and handshake parameters of the bootcfg gRPC server.
+type ServerTLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// CAFile authenticates client certificates. Required unless ClientAuth
+	// is NoClientCert.
+	CAFile       string
+	ClientAuth   ClientAuthType
+	MinVersion   uint16
+	MaxVersion   uint16
+	CipherSuites []uint16
+}
+
+// ClientTLSOptions configures the certificate, CA, and handshake parameters
+// a client uses to dial the bootcfg gRPC server.
+type ClientTLSOptions struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+	// ServerName overrides the server name used to verify the server's
+	// certificate and for SNI, e.g. when dialing by IP address.
+	ServerName   string
+	MinVersion   uint16
+	MaxVersion   uint16
+	CipherSuites []uint16
+}
+
+// ClientConfig builds a tls.Config suitable for dialing a bootcfg gRPC
+// server secured with ServerTLSOptions.
+func (o ClientTLSOptions) ClientConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:   o.ServerName,
+		MinVersion:   o.MinVersion,
+		MaxVersion:   o.MaxVersion,
+		CipherSuites: o.CipherSuites,
+	}
+	if o.CertFile != "" && o.KeyFile != "" {
+		cert, err := loadCertificate(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{*cert}
+	}
+	if o.CAFile != "" {
+		pool, err := loadCertPool(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+func loadCertificate(certFile, keyFile string) (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errInvalidCA
+	}
+	return pool, nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var errInvalidCA = errors.New("tlsutil: unable to parse CA certificate")
+
+// Watcher keeps a server certificate and client CA pool loaded from disk,
+// reloading them on a timer and whenever the underlying files change so
+// existing connections keep their negotiated certificate while new
+// handshakes pick up rotated material.
+type Watcher struct {
+	opts ServerTLSOptions
+
+	cert atomic.Value // *tls.Certificate
+	pool atomic.Value // *x509.CertPool
+}
+
+// NewWatcher loads the certificate and, if required by opts.ClientAuth, the
+// CA pool named by opts, and returns a Watcher serving that material.
+func NewWatcher(opts ServerTLSOptions) (*Watcher, error) {
+	w := &Watcher{opts: opts}
+	if err := w.reload(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Watch starts reloading the certificate/CA pool every refresh, and
+// immediately on any fsnotify event for the watched files. Watch blocks
+// until stop is closed; reload errors are reported to onError.
+//
+// Watch watches the containing directories of the certificate/key/CA files,
+// not the files themselves: rotation tools (ACME clients, step-ca, and
+// secret-mount style updates) typically replace a certificate by writing a
+// new file and renaming it over the old one, which moves the original inode
+// out from under an fsnotify watch on the file path, silently ending event
+// delivery after the first rotation.
+func (w *Watcher) Watch(refresh time.Duration, stop <-chan struct{}, onError func(error)) error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer fsw.Close()
+
+	watched := make(map[string]bool)
+	for _, f := range w.watchedFiles() {
+		watched[filepath.Clean(f)] = true
+	}
+	dirs := make(map[string]bool)
+	for f := range watched {
+		dirs[filepath.Dir(f)] = true
+	}
+	for d := range dirs {
+		if err := fsw.Add(d); err != nil {
+			return err
+		}
+	}
+
+	var ticker *time.Ticker
+	var tickerC <-chan time.Time
+	if refresh > 0 {
+		ticker = time.NewTicker(refresh)
+		tickerC = ticker.C
+		defer ticker.Stop()
+	}
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case ev := <-fsw.Events:
+			if !watched[filepath.Clean(ev.Name)] {
+				continue
+			}
+			if err := w.reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		case <-tickerC:
+			if err := w.reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		case err := <-fsw.Errors:
+			if onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func (w *Watcher) watchedFiles() []string {
+	files := []string{w.opts.CertFile, w.opts.KeyFile}
+	if w.opts.ClientAuth != NoClientCert {
+		files = append(files, w.opts.CAFile)
+	}
+	return files
+}
+
+func (w *Watcher) reload() error {
+	cert, err := loadCertificate(w.opts.CertFile, w.opts.KeyFile)
+	if err != nil {
+		return err
+	}
+	w.cert.Store(cert)
+
+	if w.opts.ClientAuth == NoClientCert {
+		return nil
+	}
+	pool, err := loadCertPool(w.opts.CAFile)
+	if err != nil {
+		return err
+	}
+	w.pool.Store(pool)
+	return nil
+}
+
+// Certificate returns the most recently loaded server certificate.
+func (w *Watcher) Certificate() *tls.Certificate {
+	return w.cert.Load().(*tls.Certificate)
+}
+
+// CertPool returns the most recently loaded client CA pool, or nil if
+// ClientAuth is NoClientCert.
+func (w *Watcher) CertPool() *x509.CertPool {
+	pool, _ := w.pool.Load().(*x509.CertPool)
+	return pool
+}
+
+// ServerConfig returns a tls.Config that always serves the Watcher's
+// current certificate and applies its ClientAuth policy against the
+// current CA pool.
+func (w *Watcher) ServerConfig() *tls.Config {
+	return &tls.Config{
+		ClientAuth:   w.opts.ClientAuth.tls(),
+		MinVersion:   w.opts.MinVersion,
+		MaxVersion:   w.opts.MaxVersion,
+		CipherSuites: w.opts.CipherSuites,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return w.Certificate(), nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{
+				Certificates: []tls.Certificate{*w.Certificate()},
+				ClientAuth:   w.opts.ClientAuth.tls(),
+				ClientCAs:    w.CertPool(),
+				MinVersion:   w.opts.MinVersion,
+				MaxVersion:   w.opts.MaxVersion,
+				CipherSuites: w.opts.CipherSuites,
+			}, nil
+		},
+	}
+}
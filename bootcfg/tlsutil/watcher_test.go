@@ -0,0 +1,191 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tlsutil
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert generates a self-signed certificate/key pair for
+// commonName and writes them as PEM files under dir, returning their paths.
+func writeTestCert(t *testing.T, dir, commonName string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+
+	certFile = filepath.Join(dir, commonName+"-cert.pem")
+	keyFile = filepath.Join(dir, commonName+"-key.pem")
+	certOut, err := os.Create(certFile)
+	if err != nil {
+		t.Fatalf("creating cert file: %v", err)
+	}
+	defer certOut.Close()
+	if err := pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+		t.Fatalf("writing cert file: %v", err)
+	}
+
+	keyOut, err := os.Create(keyFile)
+	if err != nil {
+		t.Fatalf("creating key file: %v", err)
+	}
+	defer keyOut.Close()
+	if err := pem.Encode(keyOut, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}); err != nil {
+		t.Fatalf("writing key file: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestWatcherReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsutil-watcher")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeTestCert(t, dir, "original")
+	w, err := NewWatcher(ServerTLSOptions{
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		ClientAuth: NoClientCert,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher: %v", err)
+	}
+
+	original := w.Certificate()
+	if original == nil {
+		t.Fatal("Certificate() returned nil after NewWatcher")
+	}
+
+	rotatedCertFile, rotatedKeyFile := writeTestCert(t, dir, "rotated")
+	rotatedCert, err := ioutil.ReadFile(rotatedCertFile)
+	if err != nil {
+		t.Fatalf("reading rotated cert: %v", err)
+	}
+	rotatedKey, err := ioutil.ReadFile(rotatedKeyFile)
+	if err != nil {
+		t.Fatalf("reading rotated key: %v", err)
+	}
+	if err := ioutil.WriteFile(certFile, rotatedCert, 0644); err != nil {
+		t.Fatalf("rotating cert file: %v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, rotatedKey, 0644); err != nil {
+		t.Fatalf("rotating key file: %v", err)
+	}
+
+	if err := w.reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+
+	rotated := w.Certificate()
+	if rotated == nil {
+		t.Fatal("Certificate() returned nil after reload")
+	}
+	if string(rotated.Certificate[0]) == string(original.Certificate[0]) {
+		t.Error("Certificate() did not change after reload with rotated material")
+	}
+}
+
+// TestWatcherWatchDetectsRenameRotation exercises Watch/fsnotify (not just
+// reload directly) against a write-then-rename rotation, the pattern ACME
+// clients and step-ca use: the rotated material lands at a temp path and is
+// renamed over the watched file, rather than being written in place.
+func TestWatcherWatchDetectsRenameRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsutil-watcher")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile := filepath.Join(dir, "server-cert.pem")
+	keyFile := filepath.Join(dir, "server-key.pem")
+	origCertFile, origKeyFile := writeTestCert(t, dir, "original")
+	if err := os.Rename(origCertFile, certFile); err != nil {
+		t.Fatalf("renaming cert into place: %v", err)
+	}
+	if err := os.Rename(origKeyFile, keyFile); err != nil {
+		t.Fatalf("renaming key into place: %v", err)
+	}
+
+	w, err := NewWatcher(ServerTLSOptions{
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		ClientAuth: NoClientCert,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher: %v", err)
+	}
+	original := w.Certificate()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go w.Watch(0, stop, func(error) {})
+
+	rotatedCertFile, rotatedKeyFile := writeTestCert(t, dir, "rotated")
+	if err := os.Rename(rotatedCertFile, certFile); err != nil {
+		t.Fatalf("rotating cert file: %v", err)
+	}
+	if err := os.Rename(rotatedKeyFile, keyFile); err != nil {
+		t.Fatalf("rotating key file: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if string(w.Certificate().Certificate[0]) != string(original.Certificate[0]) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Watch did not pick up a certificate renamed over the watched path")
+}
+
+func TestWatcherReloadInvalidCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsutil-watcher")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := NewWatcher(ServerTLSOptions{
+		CertFile: filepath.Join(dir, "missing-cert.pem"),
+		KeyFile:  filepath.Join(dir, "missing-key.pem"),
+	}); err == nil {
+		t.Error("NewWatcher with missing files: expected error, got nil")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/coreos-baremetal/bootcfg/storage"
+)
+
+var checkStoreCmd = &cobra.Command{
+	Use:   "check-store <data-path>",
+	Short: "Lint the Groups/Profiles/Ignition/Cloud-Config tree at data-path",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runCheckStore,
+}
+
+func init() {
+	rootCmd.AddCommand(checkStoreCmd)
+}
+
+func runCheckStore(cmd *cobra.Command, args []string) error {
+	store := storage.NewFileStore(&storage.Config{Root: args[0]})
+
+	profiles, err := store.Profiles()
+	if err != nil {
+		return fmt.Errorf("reading profiles: %v", err)
+	}
+	profileIDs := make(map[string]bool, len(profiles))
+	for _, p := range profiles {
+		profileIDs[p.ID] = true
+	}
+
+	groups, err := store.Groups()
+	if err != nil {
+		return fmt.Errorf("reading groups: %v", err)
+	}
+
+	var problems []string
+	for _, g := range groups {
+		if g.Profile == "" {
+			problems = append(problems, fmt.Sprintf("group %q: no profile set", g.ID))
+			continue
+		}
+		if !profileIDs[g.Profile] {
+			problems = append(problems, fmt.Sprintf("group %q: references unknown profile %q", g.ID, g.Profile))
+		}
+	}
+	for _, p := range profiles {
+		if p.IgnitionID != "" {
+			if _, err := store.IgnitionGet(p.IgnitionID); err != nil {
+				problems = append(problems, fmt.Sprintf("profile %q: ignition config %q: %v", p.ID, p.IgnitionID, err))
+			}
+		}
+		if p.CloudID != "" {
+			if _, err := store.CloudGet(p.CloudID); err != nil {
+				problems = append(problems, fmt.Sprintf("profile %q: cloud config %q: %v", p.ID, p.CloudID, err))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		return fmt.Errorf("%d problem(s) found", len(problems))
+	}
+
+	fmt.Printf("%d groups, %d profiles OK\n", len(groups), len(profiles))
+	return nil
+}
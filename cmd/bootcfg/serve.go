@@ -0,0 +1,509 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/syslog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	logrus_syslog "github.com/Sirupsen/logrus/hooks/syslog"
+	"github.com/coreos/go-systemd/activation"
+	"github.com/coreos/go-systemd/daemon"
+	"github.com/coreos/go-systemd/journal"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+
+	web "github.com/coreos/coreos-baremetal/bootcfg/http"
+	"github.com/coreos/coreos-baremetal/bootcfg/rpc"
+	"github.com/coreos/coreos-baremetal/bootcfg/server"
+	"github.com/coreos/coreos-baremetal/bootcfg/sign"
+	"github.com/coreos/coreos-baremetal/bootcfg/storage"
+	"github.com/coreos/coreos-baremetal/bootcfg/tlsutil"
+)
+
+// Defaults to info logging until -logging.format/-logging.output are parsed.
+var log = logrus.New()
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run the bootcfg HTTP and gRPC servers",
+	RunE:  runServe,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	flags := serveCmd.Flags()
+	flags.String("address", "127.0.0.1:8080", "HTTP listen address")
+	flags.String("rpc-address", "", "RPC listen address")
+	flags.String("metrics-address", "", "Prometheus metrics listen address")
+	flags.String("data-path", "/var/lib/bootcfg", "Path to data directory")
+	flags.String("assets-path", "/var/lib/bootcfg/assets", "Path to static assets")
+	flags.String("cert-file", "/etc/bootcfg/server.crt", "Path to the server TLS certificate file")
+	flags.String("key-file", "/etc/bootcfg/server.key", "Path to the server TLS key file")
+	flags.String("ca-file", "/etc/bootcfg/ca.crt", "Path to the CA verifying and authenticating client certificates")
+	flags.String("key-ring-path", "", "Path to a private keyring file")
+	flags.String("log-level", "info", "Set the logging level")
+	flags.String("storage.backend", "file", "Storage backend: file, etcd, or consul")
+	flags.StringSlice("storage.etcd.endpoints", []string{"http://127.0.0.1:2379"}, "etcd v3 endpoints")
+	flags.String("storage.etcd.prefix", "/bootcfg", "etcd key prefix for Groups/Profiles/templates")
+	flags.String("storage.etcd.cert-file", "", "Path to a TLS client certificate for etcd")
+	flags.String("storage.etcd.key-file", "", "Path to a TLS client key for etcd")
+	flags.String("storage.etcd.ca-file", "", "Path to a TLS CA for etcd")
+	flags.String("storage.consul.address", "127.0.0.1:8500", "Consul agent address")
+	flags.String("storage.consul.token", "", "Consul ACL token")
+	flags.String("storage.consul.prefix", "bootcfg", "Consul KV prefix for Groups/Profiles/templates")
+	flags.String("logging.format", "", "Log formatter to use: text, json, or journald (auto-detected under systemd)")
+	flags.String("logging.output", "stdout", "Where to write logs: stdout, stderr, or syslog")
+	flags.String("logging.pkg-levels", "", "Per-package log levels, e.g. http=debug,rpc=info,storage=warn")
+	flags.Duration("metrics.refresh-interval", 30*time.Second, "How often to recompute the Groups/Profiles/machines gauges from storage; 0 disables periodic refresh")
+	flags.Duration("tls.cert-refresh", 24*time.Hour, "How often to re-read the TLS certificate, key, and CA files from disk")
+	flags.String("tls.min-version", "1.2", "Minimum TLS version to negotiate: 1.0, 1.1, or 1.2")
+	flags.String("tls.client-auth", "require-and-verify", "Client certificate policy: none, verify-if-given, or require-and-verify")
+	flags.String("tls.cipher-suites", "", "Comma-separated cipher suite allow-list, e.g. ECDHE-RSA-AES128-GCM-SHA256")
+
+	flags.VisitAll(func(f *pflag.Flag) {
+		viper.BindPFlag(f.Name, f)
+	})
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	// restrict OpenPGP passphrase to pass via environment variable only
+	passphrase := os.Getenv("BOOTCFG_PASSPHRASE")
+
+	// Read the systemd socket-activation environment exactly once: it is
+	// consumed (LISTEN_PID/LISTEN_FDS/LISTEN_FDNAMES are unset) on the first
+	// read, so calling activation.ListenersWithNames() per-socket would only
+	// ever hand the socket-activated fd to whichever listener asked first.
+	listeners, err := activation.ListenersWithNames()
+	if err != nil {
+		log.Fatalf("failed to inspect systemd socket activation: %v", err)
+	}
+
+	address := viper.GetString("address")
+	rpcAddress := viper.GetString("rpc-address")
+	metricsAddress := viper.GetString("metrics-address")
+	dataPath := viper.GetString("data-path")
+	assetsPath := viper.GetString("assets-path")
+	certFile := viper.GetString("cert-file")
+	keyFile := viper.GetString("key-file")
+	caFile := viper.GetString("ca-file")
+	keyRingPath := viper.GetString("key-ring-path")
+
+	// validate arguments
+	if finfo, err := os.Stat(dataPath); err != nil || !finfo.IsDir() {
+		log.Fatal("A valid data-path is required")
+	}
+	if assetsPath != "" {
+		if finfo, err := os.Stat(assetsPath); err != nil || !finfo.IsDir() {
+			log.Fatalf("Provide a valid assets-path or '' to disable asset serving: %s", assetsPath)
+		}
+	}
+	if rpcAddress != "" {
+		if _, err := os.Stat(certFile); err != nil {
+			log.Fatalf("Provide a valid TLS server certificate with cert-file: %v", err)
+		}
+		if _, err := os.Stat(keyFile); err != nil {
+			log.Fatalf("Provide a valid TLS server key with key-file: %v", err)
+		}
+		if viper.GetString("tls.client-auth") != "none" {
+			if _, err := os.Stat(caFile); err != nil {
+				log.Fatalf("Provide a valid TLS certificate authority with ca-file: %v", err)
+			}
+		}
+	}
+
+	// logging setup
+	lvl, err := logrus.ParseLevel(viper.GetString("log-level"))
+	if err != nil {
+		log.Fatalf("invalid log-level: %v", err)
+	}
+	pkgLevels, err := parsePkgLevels(viper.GetString("logging.pkg-levels"))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	base, err := newBaseLogger(viper.GetString("logging.format"), viper.GetString("logging.output"))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	base.Level = lvl
+	log = base
+	httpLog := pkgLogger(base, pkgLevels, "http")
+	rpcLog := pkgLogger(base, pkgLevels, "rpc")
+	storageLog := pkgLogger(base, pkgLevels, "storage")
+
+	// (optional) signing
+	var signer, armoredSigner sign.Signer
+	if keyRingPath != "" {
+		entity, err := sign.LoadGPGEntity(keyRingPath, passphrase)
+		if err != nil {
+			log.Fatal(err)
+		}
+		signer = sign.NewGPGSigner(entity)
+		armoredSigner = sign.NewArmoredGPGSigner(entity)
+	}
+
+	// storage
+	store, err := newStore(dataPath, storageLog)
+	if err != nil {
+		log.Fatalf("failed to initialize storage backend: %v", err)
+	}
+
+	// core logic
+	core := server.NewServer(&server.Config{
+		Store: store,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-sigCh
+		log.Infof("received %s, shutting down", sig)
+		cancel()
+	}()
+
+	// gRPC Server (feature disabled by default)
+	var grpcServer *grpc.Server
+	if rpcAddress != "" {
+		log.Infof("starting bootcfg gRPC server on %s", rpcAddress)
+		log.Infof("Using TLS server certificate: %s", certFile)
+		log.Infof("Using TLS server key: %s", keyFile)
+		log.Infof("Using CA certificate: %s to authenticate client certificates", caFile)
+		lis, err := socketListener(listeners, "rpc", rpcAddress)
+		if err != nil {
+			log.Fatalf("failed to start listening: %v", err)
+		}
+
+		clientAuth, err := parseClientAuth(viper.GetString("tls.client-auth"))
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		minVersion, err := parseTLSVersion(viper.GetString("tls.min-version"))
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		suites, err := parseCipherSuites(viper.GetString("tls.cipher-suites"))
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		tlsOpts := tlsutil.ServerTLSOptions{
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			CAFile:       caFile,
+			ClientAuth:   clientAuth,
+			MinVersion:   minVersion,
+			CipherSuites: suites,
+		}
+		tlsWatcher, err := tlsutil.NewWatcher(tlsOpts)
+		if err != nil {
+			log.Fatalf("Invalid TLS credentials: %v", err)
+		}
+		stopWatch := make(chan struct{})
+		go func() {
+			<-ctx.Done()
+			close(stopWatch)
+		}()
+		go func() {
+			err := tlsWatcher.Watch(viper.GetDuration("tls.cert-refresh"), stopWatch, func(err error) {
+				log.Warnf("failed to reload TLS credentials: %v", err)
+			})
+			if err != nil {
+				log.Warnf("TLS credential watch stopped: %v", err)
+			}
+		}()
+		grpcServer = rpc.NewServer(core, tlsWatcher.ServerConfig(), rpcLog)
+		go grpcServer.Serve(lis)
+	}
+
+	// HTTP Server
+	config := &web.Config{
+		Core:          core,
+		Logger:        httpLog,
+		AssetsPath:    assetsPath,
+		Signer:        signer,
+		ArmoredSigner: armoredSigner,
+	}
+	httpServer := web.NewServer(config)
+
+	if err := httpServer.RefreshStorageGauges(); err != nil {
+		log.Warnf("failed to load initial storage metrics: %v", err)
+	}
+	if refreshInterval := viper.GetDuration("metrics.refresh-interval"); refreshInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(refreshInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := httpServer.RefreshStorageGauges(); err != nil {
+						log.Warnf("failed to refresh storage metrics: %v", err)
+					}
+				}
+			}
+		}()
+	}
+
+	// Metrics Server (feature disabled by default)
+	if metricsAddress != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", promhttp.Handler())
+		log.Infof("starting bootcfg metrics server on %s", metricsAddress)
+		metricsLis, err := socketListener(listeners, "metrics", metricsAddress)
+		if err != nil {
+			log.Fatalf("failed to start listening: %v", err)
+		}
+		go http.Serve(metricsLis, metricsMux)
+	}
+
+	lis, err := socketListener(listeners, "http", address)
+	if err != nil {
+		log.Fatalf("failed to start listening: %v", err)
+	}
+	httpSrv := &http.Server{Addr: address, Handler: httpServer.HTTPHandler()}
+
+	go func() {
+		<-ctx.Done()
+		log.Info("gracefully shutting down bootcfg HTTP server")
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer shutdownCancel()
+		httpSrv.Shutdown(shutdownCtx)
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+		if err := store.Close(); err != nil {
+			log.Warnf("failed to close storage backend: %v", err)
+		}
+	}()
+
+	daemon.SdNotify(false, "READY=1")
+	log.Infof("starting bootcfg HTTP server on %s", address)
+	if err := httpSrv.Serve(lis); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("failed to start listening: %v", err)
+	}
+	daemon.SdNotify(false, "STOPPING=1")
+	return nil
+}
+
+// socketListener returns the systemd socket-activated listener registered
+// under name (via FileDescriptorName= in the .socket unit) out of listeners,
+// falling back to net.Listen(addr) when bootcfg was not started by systemd.
+// listeners must come from a single activation.ListenersWithNames() call
+// shared across all sockets: go-systemd clears LISTEN_PID/LISTEN_FDS/
+// LISTEN_FDNAMES the first time it reads them, so calling it again per
+// socket would silently lose the fds for every socket but the first.
+func socketListener(listeners map[string][]net.Listener, name, addr string) (net.Listener, error) {
+	if lis, ok := listeners[name]; ok && len(lis) > 0 {
+		return lis[0], nil
+	}
+	return net.Listen("tcp", addr)
+}
+
+// journaldHook writes log entries to the systemd journal, preserving
+// structured fields as journal fields.
+type journaldHook struct{}
+
+func (journaldHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (journaldHook) Fire(entry *logrus.Entry) error {
+	priority := journal.PriInfo
+	switch entry.Level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		priority = journal.PriCrit
+	case logrus.ErrorLevel:
+		priority = journal.PriErr
+	case logrus.WarnLevel:
+		priority = journal.PriWarning
+	case logrus.DebugLevel:
+		priority = journal.PriDebug
+	}
+	vars := make(map[string]string, len(entry.Data))
+	for k, v := range entry.Data {
+		vars[strings.ToUpper(k)] = fmt.Sprintf("%v", v)
+	}
+	return journal.Send(entry.Message, priority, vars)
+}
+
+// syncWriter serializes writes to w behind its own mutex. pkgLogger derives
+// several independent *logrus.Logger values that each guard Out with their
+// own unexported mutex, so without this they could interleave partial lines
+// when writing concurrently to the same underlying file or socket.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// newBaseLogger builds the root logger from the logging.format/logging.output
+// config. "journald" is auto-detected when bootcfg is run directly under
+// systemd (PPID 1).
+func newBaseLogger(format, output string) (*logrus.Logger, error) {
+	base := logrus.New()
+
+	if format == "journald" || (format == "" && os.Getppid() == 1) {
+		base.Formatter = &logrus.TextFormatter{DisableTimestamp: true}
+		base.Hooks.Add(journaldHook{})
+		base.Out = ioutil.Discard
+		return base, nil
+	}
+
+	switch format {
+	case "json":
+		base.Formatter = &logrus.JSONFormatter{}
+	case "text", "":
+		base.Formatter = &logrus.TextFormatter{}
+	default:
+		return nil, fmt.Errorf("unknown logging.format %q", format)
+	}
+
+	switch output {
+	case "stdout", "":
+		base.Out = &syncWriter{w: os.Stdout}
+	case "stderr":
+		base.Out = &syncWriter{w: os.Stderr}
+	case "syslog":
+		hook, err := logrus_syslog.NewSyslogHook("", "", syslog.LOG_INFO, "bootcfg")
+		if err != nil {
+			return nil, err
+		}
+		base.Hooks.Add(hook)
+		base.Out = ioutil.Discard
+	default:
+		return nil, fmt.Errorf("unknown logging.output %q", output)
+	}
+	return base, nil
+}
+
+// parsePkgLevels parses a "pkg=level,pkg=level" value (e.g.
+// "http=debug,rpc=info,storage=warn") into per-package logrus levels.
+func parsePkgLevels(s string) (map[string]logrus.Level, error) {
+	levels := make(map[string]logrus.Level)
+	if s == "" {
+		return levels, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid logging.pkg-levels entry %q", pair)
+		}
+		lvl, err := logrus.ParseLevel(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid logging.pkg-levels entry %q: %v", pair, err)
+		}
+		levels[kv[0]] = lvl
+	}
+	return levels, nil
+}
+
+// pkgLogger returns a logger for pkg sharing base's formatter, output, and
+// hooks but with its own level, defaulting to base's level if pkg is not
+// named in levels. base.Out must be safe for concurrent writes (see
+// syncWriter), since the returned logger writes to it under its own,
+// independent mutex rather than base's.
+func pkgLogger(base *logrus.Logger, levels map[string]logrus.Level, pkg string) *logrus.Logger {
+	l := &logrus.Logger{
+		Out:       base.Out,
+		Formatter: base.Formatter,
+		Hooks:     base.Hooks,
+		Level:     base.Level,
+	}
+	if lvl, ok := levels[pkg]; ok {
+		l.Level = lvl
+	}
+	return l
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+}
+
+// parseTLSVersion parses a "1.0"/"1.1"/"1.2" config value into a
+// tls.VersionTLSxx constant. An empty string leaves the version
+// unconstrained.
+func parseTLSVersion(s string) (uint16, error) {
+	if s == "" {
+		return 0, nil
+	}
+	v, ok := tlsVersions[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown tls.min-version %q", s)
+	}
+	return v, nil
+}
+
+var clientAuthTypes = map[string]tlsutil.ClientAuthType{
+	"none":               tlsutil.NoClientCert,
+	"verify-if-given":    tlsutil.VerifyClientCertIfGiven,
+	"require-and-verify": tlsutil.RequireAndVerifyClientCert,
+}
+
+// parseClientAuth parses the tls.client-auth config value into a
+// tlsutil.ClientAuthType.
+func parseClientAuth(s string) (tlsutil.ClientAuthType, error) {
+	auth, ok := clientAuthTypes[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown tls.client-auth %q", s)
+	}
+	return auth, nil
+}
+
+var cipherSuiteNames = map[string]uint16{
+	"ECDHE-RSA-AES128-GCM-SHA256":   tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"ECDHE-RSA-AES256-GCM-SHA384":   tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"ECDHE-ECDSA-AES128-GCM-SHA256": tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"ECDHE-ECDSA-AES256-GCM-SHA384": tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+}
+
+// parseCipherSuites parses a comma-separated list of cipher suite names
+// from tls.cipher-suites into their tls package constants.
+func parseCipherSuites(s string) ([]uint16, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var suites []uint16
+	for _, name := range strings.Split(s, ",") {
+		suite, ok := cipherSuiteNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown tls.cipher-suites entry %q", name)
+		}
+		suites = append(suites, suite)
+	}
+	return suites, nil
+}
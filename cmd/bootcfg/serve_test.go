@@ -0,0 +1,57 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestParsePkgLevels(t *testing.T) {
+	levels, err := parsePkgLevels("http=debug,rpc=info,storage=warn")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]logrus.Level{
+		"http":    logrus.DebugLevel,
+		"rpc":     logrus.InfoLevel,
+		"storage": logrus.WarnLevel,
+	}
+	for pkg, lvl := range want {
+		if levels[pkg] != lvl {
+			t.Errorf("levels[%q] = %v, want %v", pkg, levels[pkg], lvl)
+		}
+	}
+}
+
+func TestParsePkgLevelsEmpty(t *testing.T) {
+	levels, err := parsePkgLevels("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(levels) != 0 {
+		t.Errorf("expected no levels, got %v", levels)
+	}
+}
+
+func TestParsePkgLevelsInvalid(t *testing.T) {
+	cases := []string{"http", "http=bogus", "http=debug,"}
+	for _, s := range cases {
+		if _, err := parsePkgLevels(s); err == nil {
+			t.Errorf("parsePkgLevels(%q): expected error, got nil", s)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSocketListenerUsesNamedListener(t *testing.T) {
+	named, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer named.Close()
+
+	listeners := map[string][]net.Listener{"http": {named}}
+	lis, err := socketListener(listeners, "http", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("socketListener: %v", err)
+	}
+	defer lis.Close()
+	if lis != named {
+		t.Error("socketListener did not return the systemd-activated listener")
+	}
+}
+
+func TestSocketListenerFallsBackToAddr(t *testing.T) {
+	lis, err := socketListener(nil, "http", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("socketListener: %v", err)
+	}
+	defer lis.Close()
+	if lis.Addr().String() == "" {
+		t.Error("socketListener fallback did not bind a listener")
+	}
+}
+
+func TestSocketListenerIgnoresOtherNames(t *testing.T) {
+	named, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer named.Close()
+
+	// Only "rpc" is socket-activated; "http" must fall back rather than
+	// reusing "rpc"'s listener.
+	listeners := map[string][]net.Listener{"rpc": {named}}
+	lis, err := socketListener(listeners, "http", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("socketListener: %v", err)
+	}
+	defer lis.Close()
+	if lis == named {
+		t.Error("socketListener returned a listener registered under a different name")
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/coreos/coreos-baremetal/bootcfg/storage"
+)
+
+// newStore builds the storage.Store named by the storage.backend config,
+// defaulting to a FileStore rooted at dataPath.
+func newStore(dataPath string, logger *logrus.Logger) (storage.Store, error) {
+	switch backend := viper.GetString("storage.backend"); backend {
+	case "", "file":
+		return storage.NewFileStore(&storage.Config{
+			Root:   dataPath,
+			Logger: logger,
+		}), nil
+	case "etcd":
+		return storage.NewEtcdStore(&storage.EtcdConfig{
+			Endpoints: viper.GetStringSlice("storage.etcd.endpoints"),
+			Prefix:    viper.GetString("storage.etcd.prefix"),
+			TLSCert:   viper.GetString("storage.etcd.cert-file"),
+			TLSKey:    viper.GetString("storage.etcd.key-file"),
+			TLSCA:     viper.GetString("storage.etcd.ca-file"),
+		})
+	case "consul":
+		return storage.NewConsulStore(&storage.ConsulConfig{
+			Address: viper.GetString("storage.consul.address"),
+			Token:   viper.GetString("storage.consul.token"),
+			Prefix:  viper.GetString("storage.consul.prefix"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage.backend %q", backend)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/coreos/coreos-baremetal/bootcfg/tlsutil"
+)
+
+func TestParseTLSVersion(t *testing.T) {
+	cases := []struct {
+		in   string
+		want uint16
+	}{
+		{"", 0},
+		{"1.0", tls.VersionTLS10},
+		{"1.1", tls.VersionTLS11},
+		{"1.2", tls.VersionTLS12},
+	}
+	for _, c := range cases {
+		got, err := parseTLSVersion(c.in)
+		if err != nil {
+			t.Errorf("parseTLSVersion(%q): unexpected error: %v", c.in, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("parseTLSVersion(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+
+	if _, err := parseTLSVersion("1.3"); err == nil {
+		t.Error("parseTLSVersion(\"1.3\"): expected error, got nil")
+	}
+}
+
+func TestParseClientAuth(t *testing.T) {
+	cases := []struct {
+		in   string
+		want tlsutil.ClientAuthType
+	}{
+		{"none", tlsutil.NoClientCert},
+		{"verify-if-given", tlsutil.VerifyClientCertIfGiven},
+		{"require-and-verify", tlsutil.RequireAndVerifyClientCert},
+	}
+	for _, c := range cases {
+		got, err := parseClientAuth(c.in)
+		if err != nil {
+			t.Errorf("parseClientAuth(%q): unexpected error: %v", c.in, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("parseClientAuth(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+
+	if _, err := parseClientAuth("bogus"); err == nil {
+		t.Error("parseClientAuth(\"bogus\"): expected error, got nil")
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	suites, err := parseCipherSuites("ECDHE-RSA-AES128-GCM-SHA256,ECDHE-RSA-AES256-GCM-SHA384")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []uint16{
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	}
+	if len(suites) != len(want) {
+		t.Fatalf("parseCipherSuites: got %d suites, want %d", len(suites), len(want))
+	}
+	for i := range want {
+		if suites[i] != want[i] {
+			t.Errorf("suites[%d] = %v, want %v", i, suites[i], want[i])
+		}
+	}
+
+	if suites, err := parseCipherSuites(""); err != nil || suites != nil {
+		t.Errorf("parseCipherSuites(\"\") = %v, %v, want nil, nil", suites, err)
+	}
+
+	if _, err := parseCipherSuites("bogus-suite"); err == nil {
+		t.Error("parseCipherSuites(\"bogus-suite\"): expected error, got nil")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var validateConfigCmd = &cobra.Command{
+	Use:   "validate-config",
+	Short: "Validate the bootcfg config file and exit",
+	RunE:  runValidateConfig,
+}
+
+func init() {
+	rootCmd.AddCommand(validateConfigCmd)
+}
+
+func runValidateConfig(cmd *cobra.Command, args []string) error {
+	var problems []string
+
+	dataPath := viper.GetString("data-path")
+	if finfo, err := os.Stat(dataPath); err != nil || !finfo.IsDir() {
+		problems = append(problems, fmt.Sprintf("data-path %q is not a directory", dataPath))
+	}
+
+	assetsPath := viper.GetString("assets-path")
+	if assetsPath != "" {
+		if finfo, err := os.Stat(assetsPath); err != nil || !finfo.IsDir() {
+			problems = append(problems, fmt.Sprintf("assets-path %q is not a directory", assetsPath))
+		}
+	}
+
+	if viper.GetString("rpc-address") != "" {
+		if _, err := parseClientAuth(viper.GetString("tls.client-auth")); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if _, err := parseTLSVersion(viper.GetString("tls.min-version")); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if _, err := parseCipherSuites(viper.GetString("tls.cipher-suites")); err != nil {
+			problems = append(problems, err.Error())
+		}
+		for _, f := range []string{viper.GetString("cert-file"), viper.GetString("key-file")} {
+			if _, err := os.Stat(f); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", f, err))
+			}
+		}
+	}
+
+	if _, err := parsePkgLevels(viper.GetString("logging.pkg-levels")); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	switch backend := viper.GetString("storage.backend"); backend {
+	case "", "file":
+	case "etcd":
+		if len(viper.GetStringSlice("storage.etcd.endpoints")) == 0 {
+			problems = append(problems, "storage.etcd.endpoints must list at least one etcd endpoint")
+		}
+	case "consul":
+		if viper.GetString("storage.consul.address") == "" {
+			problems = append(problems, "storage.consul.address is required")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown storage.backend %q", backend))
+	}
+
+	if len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		return fmt.Errorf("%d problem(s) found", len(problems))
+	}
+
+	fmt.Println("config OK")
+	return nil
+}